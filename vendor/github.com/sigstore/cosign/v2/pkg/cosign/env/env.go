@@ -0,0 +1,129 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package env centralizes the environment variables cosign reads, so every
+// variable is documented in one place instead of being sprinkled through
+// os.Getenv/os.LookupEnv calls across providers.
+package env
+
+import "os"
+
+// VariableOpts documents a single environment variable: what it's for,
+// what kind of value it expects, and whether its value should be treated
+// as sensitive (e.g. omitted from logs).
+type VariableOpts struct {
+	Description string
+	Expects     string
+	Sensitive   bool
+}
+
+// Variable is the name of an environment variable cosign reads.
+type Variable string
+
+func (v Variable) String() string {
+	return string(v)
+}
+
+const (
+	// VariableGitLabToken is the GitLab personal/project access token used
+	// to authenticate API requests.
+	VariableGitLabToken Variable = "GITLAB_TOKEN"
+
+	// VariableGitLabHost overrides the default gitlab.com base URL, for
+	// self-hosted GitLab instances.
+	VariableGitLabHost Variable = "GITLAB_HOST"
+
+	// VariableGitLabCABundle is a path to a PEM-encoded CA bundle used to
+	// verify a self-hosted GitLab instance's TLS certificate.
+	VariableGitLabCABundle Variable = "GITLAB_CA_BUNDLE"
+
+	// VariableGitLabInsecure disables TLS certificate verification when set
+	// to "true" or "1". Intended for local testing only.
+	VariableGitLabInsecure Variable = "GITLAB_INSECURE"
+
+	// VariableHTTPSProxy is the proxy URL used for HTTPS requests to
+	// GitLab.
+	VariableHTTPSProxy Variable = "HTTPS_PROXY"
+
+	// VariableGitLabMaxAttempts bounds the total number of attempts, the
+	// first one included, for a GitLab request that gets a 429 or 5xx
+	// response.
+	VariableGitLabMaxAttempts Variable = "GITLAB_MAX_ATTEMPTS"
+
+	// VariableGitLabProtected sets whether created CI/CD variables are
+	// restricted to protected branches/tags when set to "true" or "1".
+	VariableGitLabProtected Variable = "COSIGN_GITLAB_PROTECTED"
+
+	// VariableGitLabMasked sets whether created CI/CD variables are masked
+	// in job logs when set to "true" or "1".
+	VariableGitLabMasked Variable = "COSIGN_GITLAB_MASKED"
+
+	// VariableGitLabEnvScope sets the environment scope created CI/CD
+	// variables are restricted to, e.g. "production". Defaults to "*".
+	VariableGitLabEnvScope Variable = "COSIGN_GITLAB_ENV_SCOPE"
+)
+
+// variables documents every Variable cosign reads, for tooling that wants
+// to print a reference (e.g. `cosign env`).
+var variables = map[Variable]VariableOpts{
+	VariableGitLabToken: {
+		Description: "GitLab token that can read/write CI/CD variables for the target project or group",
+		Expects:     "string",
+		Sensitive:   true,
+	},
+	VariableGitLabHost: {
+		Description: "Base URL of a self-hosted GitLab instance, e.g. https://gitlab.example.com",
+		Expects:     "string",
+	},
+	VariableGitLabCABundle: {
+		Description: "Path to a PEM-encoded CA bundle to verify a self-hosted GitLab instance's TLS certificate",
+		Expects:     "path",
+	},
+	VariableGitLabInsecure: {
+		Description: "Skip TLS certificate verification when talking to GitLab. For testing only",
+		Expects:     "true|false",
+	},
+	VariableHTTPSProxy: {
+		Description: "Proxy URL used for HTTPS requests to GitLab",
+		Expects:     "string",
+	},
+	VariableGitLabMaxAttempts: {
+		Description: "Maximum number of attempts for a GitLab request that hits a 429 or 5xx response",
+		Expects:     "int",
+	},
+	VariableGitLabProtected: {
+		Description: "Restrict created CI/CD variables to protected branches/tags",
+		Expects:     "true|false",
+	},
+	VariableGitLabMasked: {
+		Description: "Mask created CI/CD variables in job logs",
+		Expects:     "true|false",
+	},
+	VariableGitLabEnvScope: {
+		Description: "Environment scope created CI/CD variables are restricted to",
+		Expects:     "string",
+	},
+}
+
+// Opts returns the documented options for v, and whether v is known.
+func Opts(v Variable) (VariableOpts, bool) {
+	opts, ok := variables[v]
+	return opts, ok
+}
+
+// LookupEnv reads v from the environment, mirroring os.LookupEnv.
+func LookupEnv(v Variable) (string, bool) {
+	return os.LookupEnv(v.String())
+}
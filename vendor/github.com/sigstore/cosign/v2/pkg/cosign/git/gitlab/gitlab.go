@@ -16,10 +16,20 @@
 package gitlab
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sigstore/cosign/v2/internal/ui"
 	"github.com/sigstore/cosign/v2/pkg/cosign"
@@ -29,135 +39,749 @@ import (
 
 const (
 	ReferenceScheme = "gitlab"
+
+	// groupRefPrefix marks a ref as targeting a GitLab group rather than a
+	// project, e.g. "gitlab://groups/my-team".
+	groupRefPrefix = "groups/"
+
+	defaultProtected        = false
+	defaultMasked           = false
+	defaultEnvironmentScope = "*"
+
+	// defaultMaxAttempts bounds the total number of attempts, the first one
+	// included, for a request that gets a 429 or 5xx response before the
+	// provider gives up.
+	defaultMaxAttempts = 4
+	baseRetryBackoff   = 500 * time.Millisecond
+	maxRetryBackoff    = 30 * time.Second
 )
 
+// Gl implements the git provider interface for GitLab, backing project and
+// group CI/CD variables with cosign key material. Wiring this provider
+// into CLI subcommands (generate-key-pair --rotate, delete-key-pair) and
+// providing an equivalent for the GitHub provider are out of scope for
+// this package.
 type Gl struct{}
 
 func New() *Gl {
 	return &Gl{}
 }
 
+// parseRef splits a gitlab ref into the GitLab API target (project path/ID
+// or group path/ID) and reports whether that target is a group.
+//
+// Project targets are either a numeric ID or an "owner/project" path. Group
+// targets are either explicitly prefixed with "groups/" or are a bare name
+// with no path separator.
+func parseRef(ref string) (target string, isGroup bool) {
+	if trimmed := strings.TrimPrefix(ref, groupRefPrefix); trimmed != ref {
+		return trimmed, true
+	}
+	if _, err := strconv.Atoi(ref); err == nil {
+		return ref, false
+	}
+	if strings.Contains(ref, "/") {
+		return ref, false
+	}
+	return ref, true
+}
+
+// Options configures the transport used to reach GitLab, so self-hosted
+// instances behind an internal CA or a corporate proxy can be reached the
+// same way project/group variables are. No client certificate is
+// configured, so servers that require mutual TLS are not supported.
+type Options struct {
+	BaseURL            string
+	CABundlePath       string
+	InsecureSkipVerify bool
+	ProxyURL           string
+	Timeout            time.Duration
+
+	// MaxAttempts bounds the total number of attempts, the first one
+	// included, on 429/5xx responses. Defaults to defaultMaxAttempts when
+	// <= 0.
+	MaxAttempts int
+}
+
+func optionsFromEnv() Options {
+	opts := Options{MaxAttempts: defaultMaxAttempts}
+	if baseURL, ok := env.LookupEnv(env.VariableGitLabHost); ok {
+		opts.BaseURL = baseURL
+	}
+	if path, ok := env.LookupEnv(env.VariableGitLabCABundle); ok {
+		opts.CABundlePath = path
+	}
+	if insecure, ok := env.LookupEnv(env.VariableGitLabInsecure); ok {
+		opts.InsecureSkipVerify = insecure == "true" || insecure == "1"
+	}
+	if proxy, ok := env.LookupEnv(env.VariableHTTPSProxy); ok {
+		opts.ProxyURL = proxy
+	}
+	if maxAttempts, ok := env.LookupEnv(env.VariableGitLabMaxAttempts); ok {
+		if n, err := strconv.Atoi(maxAttempts); err == nil && n > 0 {
+			opts.MaxAttempts = n
+		}
+	}
+	return opts
+}
+
+// NewWithOptions builds a GitLab client whose HTTP transport is configured
+// from opts rather than the library defaults, for talking to self-hosted
+// instances with custom CAs or proxies. Client certificates are not
+// configured.
+func NewWithOptions(token string, opts Options) (*gitlab.Client, error) {
+	httpClient, err := httpClientFromOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("configuring GitLab HTTP client: %w", err)
+	}
+
+	clientOpts := []gitlab.ClientOptionFunc{gitlab.WithHTTPClient(httpClient)}
+	if opts.BaseURL != "" {
+		clientOpts = append(clientOpts, gitlab.WithBaseURL(opts.BaseURL))
+	}
+
+	return gitlab.NewClient(token, clientOpts...)
+}
+
+func httpClientFromOptions(opts Options) (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify} // #nosec G402 -- opt-in via GITLAB_INSECURE
+
+	if opts.CABundlePath != "" {
+		pemBytes, err := os.ReadFile(opts.CABundlePath)
+		if err != nil {
+			return nil, fmt.Errorf("reading GitLab CA bundle %q: %w", opts.CABundlePath, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemBytes) {
+			return nil, fmt.Errorf("no certificates found in GitLab CA bundle %q", opts.CABundlePath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := &http.Transport{TLSClientConfig: tlsConfig}
+	if opts.ProxyURL != "" {
+		proxyURL, err := url.Parse(opts.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing GitLab proxy URL %q: %w", opts.ProxyURL, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	return &http.Client{
+		Transport: &retryTransport{next: transport, maxAttempts: maxAttempts},
+		Timeout:   opts.Timeout,
+	}, nil
+}
+
+// retryTransport retries requests that hit GitLab rate limiting (429) or a
+// transient server error (5xx), honoring the Retry-After header on 429 and
+// backing off exponentially with jitter otherwise.
+type retryTransport struct {
+	next        http.RoundTripper
+	maxAttempts int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var resp *http.Response
+	var err error
+	for attempt := 0; attempt < t.maxAttempts; attempt++ {
+		if body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		resp, err = t.next.RoundTrip(req)
+		if err != nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500) {
+			return resp, err
+		}
+		if attempt == t.maxAttempts-1 {
+			return resp, err
+		}
+
+		wait := retryDelay(resp, attempt)
+		resp.Body.Close()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+	return resp, err
+}
+
+// retryDelay honors GitLab's Retry-After header on 429, falling back to
+// exponential backoff with full jitter for everything else.
+func retryDelay(resp *http.Response, attempt int) time.Duration {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if ra := resp.Header.Get("Retry-After"); ra != "" {
+			if secs, err := strconv.Atoi(ra); err == nil {
+				return time.Duration(secs) * time.Second
+			}
+		}
+	}
+
+	backoff := baseRetryBackoff << attempt
+	if backoff <= 0 || backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	// Full jitter: a random duration in [0, backoff), so the real ceiling
+	// stays at maxRetryBackoff instead of up to double it.
+	return time.Duration(rand.Int63n(int64(backoff))) //nolint:gosec // jitter, not security-sensitive
+}
+
+// projectVariablesAPI is the subset of gitlab.ProjectVariablesService this
+// package depends on, narrowed to an interface so failures (a rejected
+// create, a down-for-maintenance remove) can be exercised with a fake in
+// tests instead of a real GitLab API.
+type projectVariablesAPI interface {
+	CreateVariable(pid interface{}, opt *gitlab.CreateProjectVariableOptions, options ...gitlab.RequestOptionFunc) (*gitlab.ProjectVariable, *gitlab.Response, error)
+	GetVariable(pid interface{}, key string, opt *gitlab.GetProjectVariableOptions, options ...gitlab.RequestOptionFunc) (*gitlab.ProjectVariable, *gitlab.Response, error)
+	UpdateVariable(pid interface{}, key string, opt *gitlab.UpdateProjectVariableOptions, options ...gitlab.RequestOptionFunc) (*gitlab.ProjectVariable, *gitlab.Response, error)
+	RemoveVariable(pid interface{}, key string, opt *gitlab.RemoveProjectVariableOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Response, error)
+}
+
+// groupVariablesAPI is the group-level equivalent of projectVariablesAPI.
+type groupVariablesAPI interface {
+	CreateVariable(gid interface{}, opt *gitlab.CreateGroupVariableOptions, options ...gitlab.RequestOptionFunc) (*gitlab.GroupVariable, *gitlab.Response, error)
+	GetVariable(gid interface{}, key string, opt *gitlab.GetGroupVariableOptions, options ...gitlab.RequestOptionFunc) (*gitlab.GroupVariable, *gitlab.Response, error)
+	UpdateVariable(gid interface{}, key string, opt *gitlab.UpdateGroupVariableOptions, options ...gitlab.RequestOptionFunc) (*gitlab.GroupVariable, *gitlab.Response, error)
+	RemoveVariable(gid interface{}, key string, opt *gitlab.RemoveGroupVariableOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Response, error)
+}
+
+func newClient() (*gitlab.Client, error) {
+	token, tokenExists := env.LookupEnv(env.VariableGitLabToken)
+	if !tokenExists {
+		return nil, fmt.Errorf("could not find %q", env.VariableGitLabToken.String())
+	}
+
+	return NewWithOptions(token, optionsFromEnv())
+}
+
+// PutSecretOptions controls the attributes GitLab CI/CD variables are
+// created with. The zero value is not meaningful on its own; use
+// putSecretOptionsFromEnv to get env-derived defaults before overriding
+// individual fields.
+type PutSecretOptions struct {
+	Protected        bool
+	Masked           bool
+	EnvironmentScope string
+}
+
+func putSecretOptionsFromEnv() PutSecretOptions {
+	opts := PutSecretOptions{
+		Protected:        defaultProtected,
+		Masked:           defaultMasked,
+		EnvironmentScope: defaultEnvironmentScope,
+	}
+	if protected, ok := env.LookupEnv(env.VariableGitLabProtected); ok {
+		opts.Protected = protected == "true" || protected == "1"
+	}
+	if masked, ok := env.LookupEnv(env.VariableGitLabMasked); ok {
+		opts.Masked = masked == "true" || masked == "1"
+	}
+	if scope, ok := env.LookupEnv(env.VariableGitLabEnvScope); ok {
+		opts.EnvironmentScope = scope
+	}
+	return opts
+}
+
+// gitlabMaskableValue matches GitLab's requirements for a maskable CI/CD
+// variable value (8+ characters, each a letter, a digit or one of
+// _+=/@:.~-, so no whitespace or newlines):
+// https://docs.gitlab.com/ee/ci/variables/#mask-a-cicd-variable
+var gitlabMaskableValue = regexp.MustCompile(`^[a-zA-Z0-9_+=/@:.~-]{8,}$`)
+
+// resolveMasked reports whether value should be masked, falling back to
+// unmasked (with a warning) when Masked is requested but value doesn't meet
+// GitLab's masking regex.
+func resolveMasked(ctx context.Context, opts PutSecretOptions, label, value string) bool {
+	if !opts.Masked {
+		return false
+	}
+	if !gitlabMaskableValue.MatchString(value) {
+		ui.Warnf(ctx, "%q does not meet GitLab's masking requirements; creating it unmasked", label)
+		return false
+	}
+	return true
+}
+
+// PutSecret generates a cosign key pair and writes it as CI/CD variables on
+// the project or group ref resolves to (see parseRef). Routing a
+// "gitlab://groups/<group>" or bare group name ref here is the provider's
+// half of group-variable support; accepting that ref form in the
+// generate-key-pair CLI command itself is out of scope for this package, as
+// the command isn't part of this checkout.
 func (g *Gl) PutSecret(ctx context.Context, ref string, pf cosign.PassFunc) error {
 	keys, err := cosign.GenerateKeyPair(pf)
 	if err != nil {
 		return fmt.Errorf("generating key pair: %w", err)
 	}
 
-	token, tokenExists := env.LookupEnv(env.VariableGitLabToken)
-
-	if !tokenExists {
-		return fmt.Errorf("could not find %q", env.VariableGitLabToken.String())
+	client, err := newClient()
+	if err != nil {
+		return fmt.Errorf("could not create GitLab client: %w", err)
 	}
 
-	var client *gitlab.Client
-	if url, baseURLExists := env.LookupEnv(env.VariableGitLabHost); baseURLExists {
-		client, err = gitlab.NewClient(token, gitlab.WithBaseURL(url))
-		if err != nil {
-			return fmt.Errorf("could not create GitLab client: %w", err)
+	opts := putSecretOptionsFromEnv()
+
+	target, isGroup := parseRef(ref)
+	if isGroup {
+		if err := putGroupSecret(ctx, client.GroupVariables, target, keys, opts); err != nil {
+			return err
 		}
 	} else {
-		client, err = gitlab.NewClient(token)
-		if err != nil {
-			return fmt.Errorf("could not create GitLab client: %w", err)
+		if err := putProjectSecret(ctx, client.ProjectVariables, target, keys, opts); err != nil {
+			return err
 		}
 	}
 
-	_, passwordResp, err := client.ProjectVariables.CreateVariable(ref, &gitlab.CreateProjectVariableOptions{
+	if err := os.WriteFile("cosign.pub", keys.PublicBytes, 0o600); err != nil {
+		return err
+	}
+	ui.Infof(ctx, "Public key also written to cosign.pub")
+
+	return nil
+}
+
+func putProjectSecret(ctx context.Context, pv projectVariablesAPI, project string, keys *cosign.KeysBytes, opts PutSecretOptions) error {
+	var created []string
+	rollback := func() {
+		for _, key := range created {
+			resp, err := pv.RemoveVariable(project, key, nil)
+			if err != nil {
+				ui.Warnf(ctx, "could not roll back %q variable after a failed write: %v", key, err)
+				continue
+			}
+			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+				bodyBytes, _ := io.ReadAll(resp.Body)
+				ui.Warnf(ctx, "could not roll back %q variable after a failed write: %s", key, bodyBytes)
+			}
+		}
+	}
+
+	password := string(keys.Password())
+	_, passwordResp, err := pv.CreateVariable(project, &gitlab.CreateProjectVariableOptions{
 		Key:              gitlab.Ptr("COSIGN_PASSWORD"),
-		Value:            gitlab.Ptr(string(keys.Password())),
+		Value:            gitlab.Ptr(password),
 		VariableType:     gitlab.Ptr(gitlab.EnvVariableType),
-		Protected:        gitlab.Ptr(false),
-		Masked:           gitlab.Ptr(false),
-		EnvironmentScope: gitlab.Ptr("*"),
+		Protected:        gitlab.Ptr(opts.Protected),
+		Masked:           gitlab.Ptr(resolveMasked(ctx, opts, "COSIGN_PASSWORD", password)),
+		EnvironmentScope: gitlab.Ptr(opts.EnvironmentScope),
 	})
 	if err != nil {
 		ui.Warnf(ctx, "If you are using a self-hosted gitlab please set the \"GITLAB_HOST\" your server name.")
 		return fmt.Errorf("could not create \"COSIGN_PASSWORD\" variable: %w", err)
 	}
 
-	if passwordResp.StatusCode < 200 && passwordResp.StatusCode >= 300 {
+	if passwordResp.StatusCode < 200 || passwordResp.StatusCode >= 300 {
 		bodyBytes, _ := io.ReadAll(passwordResp.Body)
 		return fmt.Errorf("%s", bodyBytes)
 	}
+	created = append(created, "COSIGN_PASSWORD")
 
 	ui.Infof(ctx, "Password written to \"COSIGN_PASSWORD\" variable")
 
-	_, privateKeyResp, err := client.ProjectVariables.CreateVariable(ref, &gitlab.CreateProjectVariableOptions{
-		Key:          gitlab.Ptr("COSIGN_PRIVATE_KEY"),
-		Value:        gitlab.Ptr(string(keys.PrivateBytes)),
-		VariableType: gitlab.Ptr(gitlab.EnvVariableType),
-		Protected:    gitlab.Ptr(false),
-		Masked:       gitlab.Ptr(false),
+	privateKey := string(keys.PrivateBytes)
+	_, privateKeyResp, err := pv.CreateVariable(project, &gitlab.CreateProjectVariableOptions{
+		Key:              gitlab.Ptr("COSIGN_PRIVATE_KEY"),
+		Value:            gitlab.Ptr(privateKey),
+		VariableType:     gitlab.Ptr(gitlab.EnvVariableType),
+		Protected:        gitlab.Ptr(opts.Protected),
+		Masked:           gitlab.Ptr(resolveMasked(ctx, opts, "COSIGN_PRIVATE_KEY", privateKey)),
+		EnvironmentScope: gitlab.Ptr(opts.EnvironmentScope),
 	})
 	if err != nil {
+		rollback()
 		return fmt.Errorf("could not create \"COSIGN_PRIVATE_KEY\" variable: %w", err)
 	}
 
-	if privateKeyResp.StatusCode < 200 && privateKeyResp.StatusCode >= 300 {
+	if privateKeyResp.StatusCode < 200 || privateKeyResp.StatusCode >= 300 {
 		bodyBytes, _ := io.ReadAll(privateKeyResp.Body)
+		rollback()
 		return fmt.Errorf("%s", bodyBytes)
 	}
+	created = append(created, "COSIGN_PRIVATE_KEY")
 
 	ui.Infof(ctx, "Private key written to \"COSIGN_PRIVATE_KEY\" variable")
 
-	_, publicKeyResp, err := client.ProjectVariables.CreateVariable(ref, &gitlab.CreateProjectVariableOptions{
-		Key:          gitlab.Ptr("COSIGN_PUBLIC_KEY"),
-		Value:        gitlab.Ptr(string(keys.PublicBytes)),
-		VariableType: gitlab.Ptr(gitlab.EnvVariableType),
-		Protected:    gitlab.Ptr(false),
-		Masked:       gitlab.Ptr(false),
+	publicKey := string(keys.PublicBytes)
+	_, publicKeyResp, err := pv.CreateVariable(project, &gitlab.CreateProjectVariableOptions{
+		Key:              gitlab.Ptr("COSIGN_PUBLIC_KEY"),
+		Value:            gitlab.Ptr(publicKey),
+		VariableType:     gitlab.Ptr(gitlab.EnvVariableType),
+		Protected:        gitlab.Ptr(opts.Protected),
+		Masked:           gitlab.Ptr(resolveMasked(ctx, opts, "COSIGN_PUBLIC_KEY", publicKey)),
+		EnvironmentScope: gitlab.Ptr(opts.EnvironmentScope),
 	})
 	if err != nil {
+		rollback()
 		return fmt.Errorf("could not create \"COSIGN_PUBLIC_KEY\" variable: %w", err)
 	}
 
-	if publicKeyResp.StatusCode < 200 && publicKeyResp.StatusCode >= 300 {
+	if publicKeyResp.StatusCode < 200 || publicKeyResp.StatusCode >= 300 {
 		bodyBytes, _ := io.ReadAll(publicKeyResp.Body)
+		rollback()
 		return fmt.Errorf("%s", bodyBytes)
 	}
 
 	ui.Infof(ctx, "Public key written to \"COSIGN_PUBLIC_KEY\" variable")
 
-	if err := os.WriteFile("cosign.pub", keys.PublicBytes, 0o600); err != nil {
-		return err
+	return nil
+}
+
+func putGroupSecret(ctx context.Context, gv groupVariablesAPI, group string, keys *cosign.KeysBytes, opts PutSecretOptions) error {
+	var created []string
+	rollback := func() {
+		for _, key := range created {
+			resp, err := gv.RemoveVariable(group, key, nil)
+			if err != nil {
+				ui.Warnf(ctx, "could not roll back %q group variable after a failed write: %v", key, err)
+				continue
+			}
+			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+				bodyBytes, _ := io.ReadAll(resp.Body)
+				ui.Warnf(ctx, "could not roll back %q group variable after a failed write: %s", key, bodyBytes)
+			}
+		}
 	}
-	ui.Infof(ctx, "Public key also written to cosign.pub")
+
+	password := string(keys.Password())
+	_, passwordResp, err := gv.CreateVariable(group, &gitlab.CreateGroupVariableOptions{
+		Key:              gitlab.Ptr("COSIGN_PASSWORD"),
+		Value:            gitlab.Ptr(password),
+		VariableType:     gitlab.Ptr(gitlab.EnvVariableType),
+		Protected:        gitlab.Ptr(opts.Protected),
+		Masked:           gitlab.Ptr(resolveMasked(ctx, opts, "COSIGN_PASSWORD", password)),
+		EnvironmentScope: gitlab.Ptr(opts.EnvironmentScope),
+	})
+	if err != nil {
+		ui.Warnf(ctx, "If you are using a self-hosted gitlab please set the \"GITLAB_HOST\" your server name.")
+		return fmt.Errorf("could not create \"COSIGN_PASSWORD\" group variable: %w", err)
+	}
+
+	if passwordResp.StatusCode < 200 || passwordResp.StatusCode >= 300 {
+		bodyBytes, _ := io.ReadAll(passwordResp.Body)
+		return fmt.Errorf("%s", bodyBytes)
+	}
+	created = append(created, "COSIGN_PASSWORD")
+
+	ui.Infof(ctx, "Password written to \"COSIGN_PASSWORD\" group variable")
+
+	privateKey := string(keys.PrivateBytes)
+	_, privateKeyResp, err := gv.CreateVariable(group, &gitlab.CreateGroupVariableOptions{
+		Key:              gitlab.Ptr("COSIGN_PRIVATE_KEY"),
+		Value:            gitlab.Ptr(privateKey),
+		VariableType:     gitlab.Ptr(gitlab.EnvVariableType),
+		Protected:        gitlab.Ptr(opts.Protected),
+		Masked:           gitlab.Ptr(resolveMasked(ctx, opts, "COSIGN_PRIVATE_KEY", privateKey)),
+		EnvironmentScope: gitlab.Ptr(opts.EnvironmentScope),
+	})
+	if err != nil {
+		rollback()
+		return fmt.Errorf("could not create \"COSIGN_PRIVATE_KEY\" group variable: %w", err)
+	}
+
+	if privateKeyResp.StatusCode < 200 || privateKeyResp.StatusCode >= 300 {
+		bodyBytes, _ := io.ReadAll(privateKeyResp.Body)
+		rollback()
+		return fmt.Errorf("%s", bodyBytes)
+	}
+	created = append(created, "COSIGN_PRIVATE_KEY")
+
+	ui.Infof(ctx, "Private key written to \"COSIGN_PRIVATE_KEY\" group variable")
+
+	publicKey := string(keys.PublicBytes)
+	_, publicKeyResp, err := gv.CreateVariable(group, &gitlab.CreateGroupVariableOptions{
+		Key:              gitlab.Ptr("COSIGN_PUBLIC_KEY"),
+		Value:            gitlab.Ptr(publicKey),
+		VariableType:     gitlab.Ptr(gitlab.EnvVariableType),
+		Protected:        gitlab.Ptr(opts.Protected),
+		Masked:           gitlab.Ptr(resolveMasked(ctx, opts, "COSIGN_PUBLIC_KEY", publicKey)),
+		EnvironmentScope: gitlab.Ptr(opts.EnvironmentScope),
+	})
+	if err != nil {
+		rollback()
+		return fmt.Errorf("could not create \"COSIGN_PUBLIC_KEY\" group variable: %w", err)
+	}
+
+	if publicKeyResp.StatusCode < 200 || publicKeyResp.StatusCode >= 300 {
+		bodyBytes, _ := io.ReadAll(publicKeyResp.Body)
+		rollback()
+		return fmt.Errorf("%s", bodyBytes)
+	}
+
+	ui.Infof(ctx, "Public key written to \"COSIGN_PUBLIC_KEY\" group variable")
 
 	return nil
 }
 
 func (g *Gl) GetSecret(_ context.Context, ref string, key string) (string, error) {
-	token, tokenExists := env.LookupEnv(env.VariableGitLabToken)
-	var varPubKeyValue string
-	if !tokenExists {
-		return varPubKeyValue, fmt.Errorf("could not find %q", env.VariableGitLabToken.String())
+	var varValue string
+
+	client, err := newClient()
+	if err != nil {
+		return varValue, fmt.Errorf("could not create GitLab client: %w", err)
 	}
 
-	var client *gitlab.Client
-	var err error
-	if url, baseURLExists := env.LookupEnv(env.VariableGitLabHost); baseURLExists {
-		client, err = gitlab.NewClient(token, gitlab.WithBaseURL(url))
+	target, isGroup := parseRef(ref)
+
+	if isGroup {
+		varValue, resp, err := client.GroupVariables.GetVariable(target, key, nil)
 		if err != nil {
-			return varPubKeyValue, fmt.Errorf("could not create GitLab client): %w", err)
+			return "", fmt.Errorf("could not retrieve %q group variable: %w", key, err)
 		}
-	} else {
-		client, err = gitlab.NewClient(token)
-		if err != nil {
-			return varPubKeyValue, fmt.Errorf("could not create GitLab client: %w", err)
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			bodyBytes, _ := io.ReadAll(resp.Body)
+			return varValue.Value, fmt.Errorf("%s", bodyBytes)
 		}
+		return varValue.Value, nil
 	}
 
-	varPubKey, pubKeyResp, err := client.ProjectVariables.GetVariable(ref, key, nil)
+	varPubKey, pubKeyResp, err := client.ProjectVariables.GetVariable(target, key, nil)
 	if err != nil {
-		return varPubKeyValue, fmt.Errorf("could not retrieve \"COSIGN_PUBLIC_KEY\" variable: %w", err)
+		return varValue, fmt.Errorf("could not retrieve %q variable: %w", key, err)
 	}
 
-	varPubKeyValue = varPubKey.Value
+	varValue = varPubKey.Value
 
-	if pubKeyResp.StatusCode < 200 && pubKeyResp.StatusCode >= 300 {
+	if pubKeyResp.StatusCode < 200 || pubKeyResp.StatusCode >= 300 {
 		bodyBytes, _ := io.ReadAll(pubKeyResp.Body)
-		return varPubKeyValue, fmt.Errorf("%s", bodyBytes)
+		return varValue, fmt.Errorf("%s", bodyBytes)
+	}
+
+	return varValue, nil
+}
+
+// cosignVariableKeys are the variables written by PutSecret, in the order
+// DeleteSecret removes them and RotateSecret updates them.
+var cosignVariableKeys = []string{"COSIGN_PASSWORD", "COSIGN_PRIVATE_KEY", "COSIGN_PUBLIC_KEY"}
+
+// DeleteSecret removes the COSIGN_* variables written by PutSecret. It
+// tolerates variables that are already gone (HTTP 404), so it is safe to
+// call more than once, e.g. after a prior call partially failed.
+func (g *Gl) DeleteSecret(ctx context.Context, ref string) error {
+	client, err := newClient()
+	if err != nil {
+		return fmt.Errorf("could not create GitLab client: %w", err)
+	}
+
+	target, isGroup := parseRef(ref)
+	if isGroup {
+		return deleteGroupVariables(ctx, client.GroupVariables, target)
+	}
+	return deleteProjectVariables(ctx, client.ProjectVariables, target)
+}
+
+func deleteProjectVariables(ctx context.Context, pv projectVariablesAPI, project string) error {
+	for _, key := range cosignVariableKeys {
+		resp, err := pv.RemoveVariable(project, key, nil)
+		if resp != nil && resp.Response != nil && resp.StatusCode == http.StatusNotFound {
+			ui.Infof(ctx, "%q variable already removed", key)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("could not remove %q variable: %w", key, err)
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			bodyBytes, _ := io.ReadAll(resp.Body)
+			return fmt.Errorf("could not remove %q variable: %s", key, bodyBytes)
+		}
+		ui.Infof(ctx, "Removed %q variable", key)
+	}
+	return nil
+}
+
+func deleteGroupVariables(ctx context.Context, gv groupVariablesAPI, group string) error {
+	for _, key := range cosignVariableKeys {
+		resp, err := gv.RemoveVariable(group, key, nil)
+		if resp != nil && resp.Response != nil && resp.StatusCode == http.StatusNotFound {
+			ui.Infof(ctx, "%q group variable already removed", key)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("could not remove %q group variable: %w", key, err)
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			bodyBytes, _ := io.ReadAll(resp.Body)
+			return fmt.Errorf("could not remove %q group variable: %s", key, bodyBytes)
+		}
+		ui.Infof(ctx, "Removed %q group variable", key)
+	}
+	return nil
+}
+
+// RotateSecret replaces an existing cosign key pair with a freshly
+// generated one in place, via UpdateVariable, restoring each variable to
+// its previous value if a later update in the sequence fails. Unlike a
+// delete-then-recreate approach, this never leaves the ref with zero
+// COSIGN_* variables on a partial failure.
+func (g *Gl) RotateSecret(ctx context.Context, ref string, pf cosign.PassFunc) error {
+	keys, err := cosign.GenerateKeyPair(pf)
+	if err != nil {
+		return fmt.Errorf("generating key pair: %w", err)
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return fmt.Errorf("could not create GitLab client: %w", err)
+	}
+
+	opts := putSecretOptionsFromEnv()
+	target, isGroup := parseRef(ref)
+
+	if isGroup {
+		if err := rotateGroupSecret(ctx, client.GroupVariables, target, keys, opts); err != nil {
+			return err
+		}
+	} else {
+		if err := rotateProjectSecret(ctx, client.ProjectVariables, target, keys, opts); err != nil {
+			return err
+		}
+	}
+
+	ui.Infof(ctx, "Rotated cosign key pair for %q", ref)
+
+	return nil
+}
+
+// rotatedVariable records a variable's value before it was overwritten, so
+// it can be restored if a later update in the same rotation fails.
+type rotatedVariable struct {
+	key      string
+	oldValue string
+}
+
+func rotateProjectSecret(ctx context.Context, pv projectVariablesAPI, project string, keys *cosign.KeysBytes, opts PutSecretOptions) error {
+	newValues := map[string]string{
+		"COSIGN_PASSWORD":    string(keys.Password()),
+		"COSIGN_PRIVATE_KEY": string(keys.PrivateBytes),
+		"COSIGN_PUBLIC_KEY":  string(keys.PublicBytes),
+	}
+
+	var done []rotatedVariable
+	rollback := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			v := done[i]
+			_, resp, err := pv.UpdateVariable(project, v.key, &gitlab.UpdateProjectVariableOptions{Value: gitlab.Ptr(v.oldValue)})
+			if err != nil {
+				ui.Warnf(ctx, "could not roll back %q variable after a failed rotation: %v", v.key, err)
+				continue
+			}
+			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+				bodyBytes, _ := io.ReadAll(resp.Body)
+				ui.Warnf(ctx, "could not roll back %q variable after a failed rotation: %s", v.key, bodyBytes)
+			}
+		}
 	}
 
-	return varPubKeyValue, nil
+	for _, key := range cosignVariableKeys {
+		existing, getResp, err := pv.GetVariable(project, key, nil)
+		if err != nil {
+			rollback()
+			return fmt.Errorf("could not read existing %q variable before rotation: %w", key, err)
+		}
+		if getResp.StatusCode < 200 || getResp.StatusCode >= 300 {
+			bodyBytes, _ := io.ReadAll(getResp.Body)
+			rollback()
+			return fmt.Errorf("could not read existing %q variable before rotation: %s", key, bodyBytes)
+		}
+
+		newValue := newValues[key]
+		_, updateResp, err := pv.UpdateVariable(project, key, &gitlab.UpdateProjectVariableOptions{
+			Value:            gitlab.Ptr(newValue),
+			Protected:        gitlab.Ptr(opts.Protected),
+			Masked:           gitlab.Ptr(resolveMasked(ctx, opts, key, newValue)),
+			EnvironmentScope: gitlab.Ptr(opts.EnvironmentScope),
+		})
+		if err != nil {
+			rollback()
+			return fmt.Errorf("could not update %q variable during rotation: %w", key, err)
+		}
+		if updateResp.StatusCode < 200 || updateResp.StatusCode >= 300 {
+			bodyBytes, _ := io.ReadAll(updateResp.Body)
+			rollback()
+			return fmt.Errorf("could not update %q variable during rotation: %s", key, bodyBytes)
+		}
+		done = append(done, rotatedVariable{key: key, oldValue: existing.Value})
+
+		ui.Infof(ctx, "Rotated %q variable", key)
+	}
+
+	return nil
+}
+
+func rotateGroupSecret(ctx context.Context, gv groupVariablesAPI, group string, keys *cosign.KeysBytes, opts PutSecretOptions) error {
+	newValues := map[string]string{
+		"COSIGN_PASSWORD":    string(keys.Password()),
+		"COSIGN_PRIVATE_KEY": string(keys.PrivateBytes),
+		"COSIGN_PUBLIC_KEY":  string(keys.PublicBytes),
+	}
+
+	var done []rotatedVariable
+	rollback := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			v := done[i]
+			_, resp, err := gv.UpdateVariable(group, v.key, &gitlab.UpdateGroupVariableOptions{Value: gitlab.Ptr(v.oldValue)})
+			if err != nil {
+				ui.Warnf(ctx, "could not roll back %q group variable after a failed rotation: %v", v.key, err)
+				continue
+			}
+			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+				bodyBytes, _ := io.ReadAll(resp.Body)
+				ui.Warnf(ctx, "could not roll back %q group variable after a failed rotation: %s", v.key, bodyBytes)
+			}
+		}
+	}
+
+	for _, key := range cosignVariableKeys {
+		existing, getResp, err := gv.GetVariable(group, key, nil)
+		if err != nil {
+			rollback()
+			return fmt.Errorf("could not read existing %q group variable before rotation: %w", key, err)
+		}
+		if getResp.StatusCode < 200 || getResp.StatusCode >= 300 {
+			bodyBytes, _ := io.ReadAll(getResp.Body)
+			rollback()
+			return fmt.Errorf("could not read existing %q group variable before rotation: %s", key, bodyBytes)
+		}
+
+		newValue := newValues[key]
+		_, updateResp, err := gv.UpdateVariable(group, key, &gitlab.UpdateGroupVariableOptions{
+			Value:            gitlab.Ptr(newValue),
+			Protected:        gitlab.Ptr(opts.Protected),
+			Masked:           gitlab.Ptr(resolveMasked(ctx, opts, key, newValue)),
+			EnvironmentScope: gitlab.Ptr(opts.EnvironmentScope),
+		})
+		if err != nil {
+			rollback()
+			return fmt.Errorf("could not update %q group variable during rotation: %w", key, err)
+		}
+		if updateResp.StatusCode < 200 || updateResp.StatusCode >= 300 {
+			bodyBytes, _ := io.ReadAll(updateResp.Body)
+			rollback()
+			return fmt.Errorf("could not update %q group variable during rotation: %s", key, bodyBytes)
+		}
+		done = append(done, rotatedVariable{key: key, oldValue: existing.Value})
+
+		ui.Infof(ctx, "Rotated %q group variable", key)
+	}
+
+	return nil
 }
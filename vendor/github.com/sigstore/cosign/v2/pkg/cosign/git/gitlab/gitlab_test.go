@@ -0,0 +1,328 @@
+//
+// Copyright 2021 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		name       string
+		ref        string
+		wantTarget string
+		wantGroup  bool
+	}{
+		{"numeric project id", "1234", "1234", false},
+		{"owner slash project", "owner/project", "owner/project", false},
+		{"groups prefix", "groups/my-team", "my-team", true},
+		{"groups prefix with subgroup", "groups/my-team/subteam", "my-team/subteam", true},
+		{"bare group name", "my-team", "my-team", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, isGroup := parseRef(tt.ref)
+			if target != tt.wantTarget || isGroup != tt.wantGroup {
+				t.Errorf("parseRef(%q) = (%q, %v), want (%q, %v)", tt.ref, target, isGroup, tt.wantTarget, tt.wantGroup)
+			}
+		})
+	}
+}
+
+func TestResolveMasked(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		opts  PutSecretOptions
+		value string
+		want  bool
+	}{
+		{"masking not requested", PutSecretOptions{Masked: false}, "longenoughvalue", false},
+		{"value meets masking regex", PutSecretOptions{Masked: true}, "longenoughvalue", true},
+		{"value too short", PutSecretOptions{Masked: true}, "short", false},
+		{"value has disallowed characters", PutSecretOptions{Masked: true}, "has a space in it", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveMasked(ctx, tt.opts, "TEST_VAR", tt.value); got != tt.want {
+				t.Errorf("resolveMasked(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	t.Run("honors Retry-After on 429", func(t *testing.T) {
+		resp := &http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header:     http.Header{"Retry-After": []string{"7"}},
+		}
+		if got := retryDelay(resp, 0); got != 7*time.Second {
+			t.Errorf("retryDelay() = %v, want 7s", got)
+		}
+	})
+
+	t.Run("stays within bounds across attempt counts, including large ones", func(t *testing.T) {
+		resp := &http.Response{StatusCode: http.StatusInternalServerError}
+		for _, attempt := range []int{0, 1, 2, 5, 30, 35, 40, 62, 63, 64, 100} {
+			got := retryDelay(resp, attempt)
+			if got < 0 || got >= maxRetryBackoff {
+				t.Errorf("retryDelay(attempt=%d) = %v, want within [0, %v)", attempt, got, maxRetryBackoff)
+			}
+		}
+	})
+}
+
+// fakeRoundTripper returns canned responses in order and records the body
+// of every request it sees, so tests can assert retryTransport both
+// re-sends the request body and stops retrying once it gets a
+// non-retryable status.
+type fakeRoundTripper struct {
+	responses []*http.Response
+	bodies    []string
+	calls     int
+}
+
+func (f *fakeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := ""
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = string(b)
+	}
+	f.bodies = append(f.bodies, body)
+
+	resp := f.responses[f.calls]
+	f.calls++
+	return resp, nil
+}
+
+func newFakeResponse(status int) *http.Response {
+	return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}
+}
+
+func TestRetryTransportRoundTrip(t *testing.T) {
+	t.Run("retries a 5xx and resends the request body", func(t *testing.T) {
+		frt := &fakeRoundTripper{responses: []*http.Response{newFakeResponse(http.StatusServiceUnavailable), newFakeResponse(http.StatusOK)}}
+		transport := &retryTransport{next: frt, maxAttempts: 2}
+
+		req, err := http.NewRequest(http.MethodPost, "http://example.com", strings.NewReader("payload"))
+		if err != nil {
+			t.Fatalf("NewRequest() = %v", err)
+		}
+
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip() error = %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("RoundTrip() StatusCode = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+		if frt.calls != 2 {
+			t.Errorf("calls = %d, want 2", frt.calls)
+		}
+		for i, body := range frt.bodies {
+			if body != "payload" {
+				t.Errorf("bodies[%d] = %q, want %q", i, body, "payload")
+			}
+		}
+	})
+
+	t.Run("honors Retry-After on 429 then succeeds", func(t *testing.T) {
+		tooManyRequests := newFakeResponse(http.StatusTooManyRequests)
+		tooManyRequests.Header.Set("Retry-After", "0")
+		frt := &fakeRoundTripper{responses: []*http.Response{tooManyRequests, newFakeResponse(http.StatusOK)}}
+		transport := &retryTransport{next: frt, maxAttempts: 2}
+
+		req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		if err != nil {
+			t.Fatalf("NewRequest() = %v", err)
+		}
+
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip() error = %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("RoundTrip() StatusCode = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+		if frt.calls != 2 {
+			t.Errorf("calls = %d, want 2", frt.calls)
+		}
+	})
+
+	t.Run("gives up after maxAttempts", func(t *testing.T) {
+		frt := &fakeRoundTripper{responses: []*http.Response{newFakeResponse(http.StatusInternalServerError), newFakeResponse(http.StatusInternalServerError)}}
+		transport := &retryTransport{next: frt, maxAttempts: 2}
+
+		req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		if err != nil {
+			t.Fatalf("NewRequest() = %v", err)
+		}
+
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip() error = %v", err)
+		}
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Errorf("RoundTrip() StatusCode = %d, want %d", resp.StatusCode, http.StatusInternalServerError)
+		}
+		if frt.calls != 2 {
+			t.Errorf("calls = %d, want maxAttempts (2)", frt.calls)
+		}
+	})
+}
+
+// fakeProjectVariablesAPI implements projectVariablesAPI with canned
+// behavior per key, and records which keys were removed, so rollback can be
+// asserted without a real GitLab client.
+type fakeProjectVariablesAPI struct {
+	createErrKey string
+	createErr    error
+	values       map[string]string
+	removed      []string
+	updateErrKey string
+	updateErr    error
+}
+
+func (f *fakeProjectVariablesAPI) CreateVariable(_ interface{}, opt *gitlab.CreateProjectVariableOptions, _ ...gitlab.RequestOptionFunc) (*gitlab.ProjectVariable, *gitlab.Response, error) {
+	key := *opt.Key
+	if key == f.createErrKey {
+		return nil, nil, f.createErr
+	}
+	return &gitlab.ProjectVariable{Key: key, Value: *opt.Value}, &gitlab.Response{Response: &http.Response{StatusCode: http.StatusCreated}}, nil
+}
+
+func (f *fakeProjectVariablesAPI) GetVariable(_ interface{}, key string, _ *gitlab.GetProjectVariableOptions, _ ...gitlab.RequestOptionFunc) (*gitlab.ProjectVariable, *gitlab.Response, error) {
+	return &gitlab.ProjectVariable{Key: key, Value: f.values[key]}, &gitlab.Response{Response: &http.Response{StatusCode: http.StatusOK}}, nil
+}
+
+func (f *fakeProjectVariablesAPI) UpdateVariable(_ interface{}, key string, opt *gitlab.UpdateProjectVariableOptions, _ ...gitlab.RequestOptionFunc) (*gitlab.ProjectVariable, *gitlab.Response, error) {
+	if key == f.updateErrKey {
+		return nil, nil, f.updateErr
+	}
+	f.values[key] = *opt.Value
+	return &gitlab.ProjectVariable{Key: key, Value: *opt.Value}, &gitlab.Response{Response: &http.Response{StatusCode: http.StatusOK}}, nil
+}
+
+func (f *fakeProjectVariablesAPI) RemoveVariable(_ interface{}, key string, _ *gitlab.RemoveProjectVariableOptions, _ ...gitlab.RequestOptionFunc) (*gitlab.Response, error) {
+	f.removed = append(f.removed, key)
+	return &gitlab.Response{Response: &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(strings.NewReader(""))}}, nil
+}
+
+func testKeys(t *testing.T) *cosign.KeysBytes {
+	t.Helper()
+	keys, err := cosign.GenerateKeyPair(func(bool) ([]byte, error) { return []byte("s3cr3tpassword"), nil })
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+	return keys
+}
+
+func TestPutProjectSecretRollback(t *testing.T) {
+	pv := &fakeProjectVariablesAPI{createErrKey: "COSIGN_PUBLIC_KEY", createErr: errors.New("create failed")}
+
+	err := putProjectSecret(context.Background(), pv, "owner/project", testKeys(t), PutSecretOptions{EnvironmentScope: "*"})
+	if err == nil {
+		t.Fatal("putProjectSecret() error = nil, want an error")
+	}
+
+	want := []string{"COSIGN_PASSWORD", "COSIGN_PRIVATE_KEY"}
+	if len(pv.removed) != len(want) {
+		t.Fatalf("removed = %v, want %v", pv.removed, want)
+	}
+	for i, key := range want {
+		if pv.removed[i] != key {
+			t.Errorf("removed[%d] = %q, want %q", i, pv.removed[i], key)
+		}
+	}
+}
+
+func TestRotateProjectSecretRollback(t *testing.T) {
+	pv := &fakeProjectVariablesAPI{
+		updateErrKey: "COSIGN_PUBLIC_KEY",
+		updateErr:    errors.New("update failed"),
+		values: map[string]string{
+			"COSIGN_PASSWORD":    "old-password",
+			"COSIGN_PRIVATE_KEY": "old-private-key",
+			"COSIGN_PUBLIC_KEY":  "old-public-key",
+		},
+	}
+
+	err := rotateProjectSecret(context.Background(), pv, "owner/project", testKeys(t), PutSecretOptions{EnvironmentScope: "*"})
+	if err == nil {
+		t.Fatal("rotateProjectSecret() error = nil, want an error")
+	}
+
+	want := map[string]string{
+		"COSIGN_PASSWORD":    "old-password",
+		"COSIGN_PRIVATE_KEY": "old-private-key",
+		"COSIGN_PUBLIC_KEY":  "old-public-key",
+	}
+	for key, wantValue := range want {
+		if got := pv.values[key]; got != wantValue {
+			t.Errorf("values[%q] = %q, want %q (rotation should have rolled back)", key, got, wantValue)
+		}
+	}
+}
+
+func TestDeleteProjectVariablesTolerates404(t *testing.T) {
+	pv := &fakeProjectVariablesAPI{}
+	// Override RemoveVariable behavior via a thin wrapper so the first key
+	// reports already-removed (404) and the rest succeed normally.
+	calls := 0
+	remove := func(_ interface{}, key string, _ *gitlab.RemoveProjectVariableOptions, _ ...gitlab.RequestOptionFunc) (*gitlab.Response, error) {
+		calls++
+		status := http.StatusNoContent
+		if calls == 1 {
+			status = http.StatusNotFound
+		}
+		return &gitlab.Response{Response: &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(""))}}, nil
+	}
+
+	if err := deleteProjectVariables(context.Background(), removeOnlyProjectVariablesAPI{pv, remove}, "owner/project"); err != nil {
+		t.Fatalf("deleteProjectVariables() error = %v, want nil", err)
+	}
+	if calls != len(cosignVariableKeys) {
+		t.Errorf("RemoveVariable called %d times, want %d", calls, len(cosignVariableKeys))
+	}
+}
+
+// removeOnlyProjectVariablesAPI lets TestDeleteProjectVariablesTolerates404
+// override just RemoveVariable while reusing fakeProjectVariablesAPI for the
+// rest of the interface.
+type removeOnlyProjectVariablesAPI struct {
+	*fakeProjectVariablesAPI
+	remove func(interface{}, string, *gitlab.RemoveProjectVariableOptions, ...gitlab.RequestOptionFunc) (*gitlab.Response, error)
+}
+
+func (r removeOnlyProjectVariablesAPI) RemoveVariable(pid interface{}, key string, opt *gitlab.RemoveProjectVariableOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Response, error) {
+	return r.remove(pid, key, opt, options...)
+}